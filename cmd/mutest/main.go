@@ -0,0 +1,9 @@
+// Command mutest is the CLI entrypoint for the mutest library: it just
+// hands off to mutest.Main, which owns flag parsing and the actual run.
+package main
+
+import "github.com/frankisblissful/mutest"
+
+func main() {
+	mutest.Main()
+}
@@ -0,0 +1,166 @@
+package mutest
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// coverageProfile is the result of running `go test -coverprofile` once
+// over a package or file pair. Runner.Run and RunPackage use it to skip
+// mutating code no test exercises, rather than spending a full `go test`
+// invocation on every candidate only to watch it survive for lack of
+// coverage.
+type coverageProfile struct {
+	profiles []*cover.Profile
+}
+
+// covers reports whether pos, a position within the file named base
+// (e.g. filepath.Base(codeFilePath)), falls inside a block the coverage
+// run actually executed. A nil *coverageProfile covers everything, so
+// callers that didn't run with --coverage-guided don't need a nil check.
+func (c *coverageProfile) covers(base string, pos token.Position) bool {
+	if c == nil {
+		return true
+	}
+	for _, p := range c.profiles {
+		if filepath.Base(p.FileName) != base {
+			continue
+		}
+		for _, b := range p.Blocks {
+			if b.Count > 0 && withinBlock(b, pos) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func withinBlock(b cover.ProfileBlock, pos token.Position) bool {
+	if pos.Line < b.StartLine || pos.Line > b.EndLine {
+		return false
+	}
+	if pos.Line == b.StartLine && pos.Column < b.StartCol {
+		return false
+	}
+	if pos.Line == b.EndLine && pos.Column >= b.EndCol {
+		return false
+	}
+	return true
+}
+
+// runCoverage runs `go test -coverprofile` in dir and parses the
+// result. dir must already contain the package's source and test files
+// (a worker sandbox, or the sandboxed copy of the package directory in
+// RunPackage's case). runFilter, if non-empty, is passed as `go test -run`.
+func runCoverage(ctx context.Context, dir, runFilter string) (*coverageProfile, error) {
+	out, err := ioutil.TempFile("", "mutest-cover-")
+	if err != nil {
+		return nil, err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	args := []string{"test", "-coverprofile=" + out.Name()}
+	if runFilter != "" {
+		args = append(args, "-run", runFilter)
+	}
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("coverage run failed: %w: %s", err, output)
+	}
+
+	profiles, err := cover.ParseProfiles(out.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &coverageProfile{profiles: profiles}, nil
+}
+
+// testNames returns the name of every top-level Test function declared
+// across testFilePaths.
+func testNames(testFilePaths ...string) ([]string, error) {
+	var names []string
+	for _, path := range testFilePaths {
+		fset := token.NewFileSet()
+		astFile, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range astFile.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil {
+				continue
+			}
+			if strings.HasPrefix(fn.Name.Name, "Test") {
+				names = append(names, fn.Name.Name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// testFilter returns a `go test -run` regexp matching exactly names. It
+// returns "" if names is empty, meaning no -run filter should be
+// applied (i.e. run the whole suite).
+func testFilter(names ...string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = regexp.QuoteMeta(n)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// testCoverage maps each Test function name to the coverage profile
+// produced by running just that test, so a mutation candidate can be
+// narrowed down to only the tests that actually exercise it instead of
+// the whole suite.
+type testCoverage struct {
+	byTest map[string]*coverageProfile
+}
+
+// coveringTests returns the names of tests whose coverage includes pos
+// in the file named base. A nil *testCoverage returns nil, meaning no
+// per-test attribution is available.
+func (c *testCoverage) coveringTests(base string, pos token.Position) []string {
+	if c == nil {
+		return nil
+	}
+	var names []string
+	for name, profile := range c.byTest {
+		if profile.covers(base, pos) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// runPerTestCoverage runs each of names individually with its own
+// -coverprofile in dir, building the map coveringTests reads from. It
+// costs one `go test` invocation per test up front, in exchange for a
+// much smaller -run filter on every mutant tested afterward.
+func runPerTestCoverage(ctx context.Context, dir string, names []string) (*testCoverage, error) {
+	byTest := make(map[string]*coverageProfile, len(names))
+	for _, name := range names {
+		profile, err := runCoverage(ctx, dir, testFilter(name))
+		if err != nil {
+			return nil, err
+		}
+		byTest[name] = profile
+	}
+	return &testCoverage{byTest: byTest}, nil
+}
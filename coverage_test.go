@@ -0,0 +1,109 @@
+package mutest
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWithinBlock(t *testing.T) {
+	block := cover.ProfileBlock{StartLine: 2, StartCol: 5, EndLine: 4, EndCol: 3}
+
+	tests := []struct {
+		name string
+		pos  token.Position
+		want bool
+	}{
+		{"before start line", token.Position{Line: 1, Column: 1}, false},
+		{"after end line", token.Position{Line: 5, Column: 1}, false},
+		{"on start line before start col", token.Position{Line: 2, Column: 4}, false},
+		{"on start line at start col", token.Position{Line: 2, Column: 5}, true},
+		{"on end line at end col", token.Position{Line: 4, Column: 3}, false},
+		{"on end line before end col", token.Position{Line: 4, Column: 2}, true},
+		{"middle line", token.Position{Line: 3, Column: 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withinBlock(block, tt.pos); got != tt.want {
+				t.Errorf("withinBlock(%+v, %+v) = %v, want %v", block, tt.pos, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoverageProfileCovers(t *testing.T) {
+	profile := &coverageProfile{profiles: []*cover.Profile{
+		{
+			FileName: "calc.go",
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 1, StartCol: 1, EndLine: 3, EndCol: 1, Count: 1},
+				{StartLine: 5, StartCol: 1, EndLine: 7, EndCol: 1, Count: 0},
+			},
+		},
+	}}
+
+	if !profile.covers("calc.go", token.Position{Line: 2, Column: 1}) {
+		t.Error("covers should be true for a position inside a covered block")
+	}
+	if profile.covers("calc.go", token.Position{Line: 6, Column: 1}) {
+		t.Error("covers should be false for a position inside an uncovered (Count == 0) block")
+	}
+	if profile.covers("other.go", token.Position{Line: 2, Column: 1}) {
+		t.Error("covers should be false for a file not in the profile")
+	}
+
+	var nilProfile *coverageProfile
+	if !nilProfile.covers("calc.go", token.Position{Line: 2, Column: 1}) {
+		t.Error("a nil *coverageProfile should cover everything")
+	}
+}
+
+func TestTestNames(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "calc_test.go")
+	src := `package fixture
+
+import "testing"
+
+func TestAdd(t *testing.T) {}
+
+func TestSub(t *testing.T) {}
+
+func helper() {}
+
+func (s *suite) TestMethod(t *testing.T) {}
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := testNames(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"TestAdd": true, "TestSub": true}
+	if len(names) != len(want) {
+		t.Fatalf("testNames = %v, want exactly %v", names, want)
+	}
+	for _, n := range names {
+		if !want[n] {
+			t.Errorf("testNames returned unexpected name %q", n)
+		}
+	}
+}
+
+func TestTestFilter(t *testing.T) {
+	if got := testFilter(); got != "" {
+		t.Errorf("testFilter() = %q, want empty string for no names", got)
+	}
+	if got, want := testFilter("TestAdd"), "^(TestAdd)$"; got != want {
+		t.Errorf("testFilter(%q) = %q, want %q", "TestAdd", got, want)
+	}
+	if got, want := testFilter("TestAdd", "TestSub"), "^(TestAdd|TestSub)$"; got != want {
+		t.Errorf("testFilter(...) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,407 @@
+package mutest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// registry holds every Mutator known to the catalog, keyed by the name
+// used on the --mutators flag.
+var registry = make(map[string]Mutator)
+
+// Register adds a Mutator to the catalog under name, so it can be
+// selected from the --mutators flag or from Options.Mutators via Lookup.
+func Register(name string, m Mutator) {
+	registry[name] = m
+}
+
+func init() {
+	Register("cond", &CondMutator{})
+	Register("branch", &BranchMutator{})
+	Register("arith", &ArithMutator{})
+	Register("literal", &LiteralMutator{})
+	Register("return", &ReturnMutator{})
+	Register("statement", &StatementMutator{})
+}
+
+// Lookup resolves a list of catalog names (as given on --mutators) into
+// the Mutators they name, in order.
+func Lookup(names []string) ([]Mutator, error) {
+	mutators := make([]Mutator, 0, len(names))
+	for _, name := range names {
+		m, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown mutator %q", name)
+		}
+		mutators = append(mutators, m)
+	}
+	return mutators, nil
+}
+
+// CondMutator swaps comparison and logical operators in binary
+// expressions, e.g. == to !=, or && to ||.
+type CondMutator struct{}
+
+func (*CondMutator) Name() string { return "cond" }
+
+func (*CondMutator) Description() string {
+	return "swaps comparison and logical operators in binary expressions"
+}
+
+func (*CondMutator) CanMutate(node ast.Node) bool {
+	n, ok := node.(*ast.BinaryExpr)
+	if !ok {
+		return false
+	}
+	switch n.Op {
+	case token.LAND, token.LOR, token.EQL, token.NEQ, token.GEQ, token.LEQ, token.GTR, token.LSS:
+		return true
+	}
+	return false
+}
+
+func (*CondMutator) Mutate(node ast.Node) (string, string) {
+	n := node.(*ast.BinaryExpr)
+	before := n.Op.String()
+	switch n.Op {
+	case token.LAND:
+		n.Op = token.LOR
+	case token.LOR:
+		n.Op = token.LAND
+	case token.EQL:
+		n.Op = token.NEQ
+	case token.NEQ:
+		n.Op = token.EQL
+	case token.GEQ:
+		n.Op = token.LSS
+	case token.LEQ:
+		n.Op = token.GTR
+	case token.GTR:
+		n.Op = token.LEQ
+	case token.LSS:
+		n.Op = token.GEQ
+	default:
+		// Unrecognized operator: leave the node untouched rather than
+		// panicking, since CanMutate should already have filtered it out.
+	}
+	return before, n.Op.String()
+}
+
+func (m *CondMutator) Unmutate(node ast.Node) {
+	m.Mutate(node)
+}
+
+func (*CondMutator) Clone() Mutator { return &CondMutator{} }
+
+// BranchMutator negates branch conditions by wrapping them in a logical
+// NOT, so a taken branch is skipped and vice versa.
+type BranchMutator struct{}
+
+func (*BranchMutator) Name() string { return "branch" }
+
+func (*BranchMutator) Description() string {
+	return "negates branch conditions by wrapping them in a logical NOT"
+}
+
+// branchCond returns a pointer to node's condition field if node is an
+// if or for statement with a condition, so Mutate/Unmutate can rewrite
+// it in place. Restricting to these two node kinds (rather than any
+// *ast.UnaryExpr) keeps the mutator on boolean-typed conditions: an
+// arbitrary unary expression like -x, &x, or <-ch isn't boolean, and
+// wrapping it in "!" produces a mutant that can't compile.
+func branchCond(node ast.Node) *ast.Expr {
+	switch n := node.(type) {
+	case *ast.IfStmt:
+		if n.Cond != nil {
+			return &n.Cond
+		}
+	case *ast.ForStmt:
+		if n.Cond != nil {
+			return &n.Cond
+		}
+	}
+	return nil
+}
+
+func (*BranchMutator) CanMutate(node ast.Node) bool {
+	return branchCond(node) != nil
+}
+
+func (*BranchMutator) Mutate(node ast.Node) (string, string) {
+	cond := branchCond(node)
+	*cond = &ast.UnaryExpr{OpPos: (*cond).Pos(), Op: token.NOT, X: *cond}
+	return "condition", "!(condition)"
+}
+
+func (*BranchMutator) Unmutate(node ast.Node) {
+	cond := branchCond(node)
+	if wrapped, ok := (*cond).(*ast.UnaryExpr); ok && wrapped.Op == token.NOT {
+		*cond = wrapped.X
+	}
+}
+
+func (*BranchMutator) Clone() Mutator { return &BranchMutator{} }
+
+// ArithMutator swaps arithmetic operators (+/-, */) and compound
+// assignment operators (+=/-=).
+type ArithMutator struct{}
+
+func (*ArithMutator) Name() string { return "arith" }
+
+func (*ArithMutator) Description() string {
+	return "swaps arithmetic operators (+/-, */) and compound assignment operators (+=/-=)"
+}
+
+func (*ArithMutator) CanMutate(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.BinaryExpr:
+		switch n.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO:
+			return true
+		}
+	case *ast.AssignStmt:
+		switch n.Tok {
+		case token.ADD_ASSIGN, token.SUB_ASSIGN:
+			return true
+		}
+	}
+	return false
+}
+
+func (*ArithMutator) Mutate(node ast.Node) (string, string) {
+	switch n := node.(type) {
+	case *ast.BinaryExpr:
+		before := n.Op.String()
+		switch n.Op {
+		case token.ADD:
+			n.Op = token.SUB
+		case token.SUB:
+			n.Op = token.ADD
+		case token.MUL:
+			n.Op = token.QUO
+		case token.QUO:
+			n.Op = token.MUL
+		}
+		return before, n.Op.String()
+	case *ast.AssignStmt:
+		before := n.Tok.String()
+		switch n.Tok {
+		case token.ADD_ASSIGN:
+			n.Tok = token.SUB_ASSIGN
+		case token.SUB_ASSIGN:
+			n.Tok = token.ADD_ASSIGN
+		}
+		return before, n.Tok.String()
+	}
+	return "", ""
+}
+
+func (m *ArithMutator) Unmutate(node ast.Node) {
+	m.Mutate(node)
+}
+
+func (*ArithMutator) Clone() Mutator { return &ArithMutator{} }
+
+// LiteralMutator perturbs constant literals: numbers by +1 (there is no
+// -1 variant), strings to/from empty, and booleans by negation.
+type LiteralMutator struct {
+	orig string
+}
+
+func (*LiteralMutator) Name() string { return "literal" }
+
+func (*LiteralMutator) Description() string {
+	return "perturbs constant literals: numbers by +1, strings to/from empty, booleans by negation"
+}
+
+func (*LiteralMutator) CanMutate(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		if n.Kind == token.STRING {
+			// Skip import paths and struct tags: they aren't runtime
+			// string values, so perturbing them just breaks the build
+			// or silently changes reflection metadata nothing checks.
+			_, excluded := nonLiteralStrings.Load(n)
+			return !excluded
+		}
+		return n.Kind == token.INT || n.Kind == token.FLOAT
+	case *ast.Ident:
+		return n.Name == "true" || n.Name == "false"
+	}
+	return false
+}
+
+func (m *LiteralMutator) Mutate(node ast.Node) (string, string) {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		before := n.Value
+		switch n.Kind {
+		case token.INT:
+			n.Value = bumpInt(n.Value)
+		case token.FLOAT:
+			n.Value = bumpFloat(n.Value)
+		case token.STRING:
+			if n.Value == `""` {
+				n.Value = `"mutest"`
+			} else {
+				n.Value = `""`
+			}
+		}
+		m.orig = before
+		return before, n.Value
+	case *ast.Ident:
+		before := n.Name
+		if n.Name == "true" {
+			n.Name = "false"
+		} else {
+			n.Name = "true"
+		}
+		m.orig = before
+		return before, n.Name
+	}
+	return "", ""
+}
+
+func (m *LiteralMutator) Unmutate(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		n.Value = m.orig
+	case *ast.Ident:
+		n.Name = m.orig
+	}
+}
+
+func (*LiteralMutator) Clone() Mutator { return &LiteralMutator{} }
+
+func bumpInt(v string) string {
+	i, err := strconv.ParseInt(v, 0, 64)
+	if err != nil {
+		return v
+	}
+	return strconv.FormatInt(i+1, 10)
+}
+
+func bumpFloat(v string) string {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	return strconv.FormatFloat(f+1, 'g', -1, 64)
+}
+
+// ReturnMutator nil-ifies address-of and new(...) return values (both
+// unambiguously pointer-shaped without needing type information) and
+// zeroes numeric literal returns.
+type ReturnMutator struct {
+	orig []ast.Expr
+}
+
+func (*ReturnMutator) Name() string { return "return" }
+
+func (*ReturnMutator) Description() string {
+	return "nil-ifies address-of and new(...) return values, and zeroes numeric literal returns"
+}
+
+// returnMutable reports whether expr is one Mutate knows how to
+// perturb without a type checker: a numeric literal, an address-of
+// expression, or a call to the builtin new. Anything else (a plain
+// identifier, a string, a bool) can't be told apart from a differently
+// typed expression here, and nil-ing it risks a type-error mutant.
+func returnMutable(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return e.Kind == token.INT || e.Kind == token.FLOAT
+	case *ast.UnaryExpr:
+		return e.Op == token.AND
+	case *ast.CallExpr:
+		ident, ok := e.Fun.(*ast.Ident)
+		return ok && ident.Name == "new"
+	}
+	return false
+}
+
+func (*ReturnMutator) CanMutate(node ast.Node) bool {
+	n, ok := node.(*ast.ReturnStmt)
+	if !ok {
+		return false
+	}
+	for _, r := range n.Results {
+		if returnMutable(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ReturnMutator) Mutate(node ast.Node) (string, string) {
+	n := node.(*ast.ReturnStmt)
+	m.orig = append([]ast.Expr(nil), n.Results...)
+	for i, expr := range n.Results {
+		switch e := expr.(type) {
+		case *ast.BasicLit:
+			switch e.Kind {
+			case token.INT:
+				n.Results[i] = &ast.BasicLit{Kind: token.INT, Value: "0"}
+			case token.FLOAT:
+				n.Results[i] = &ast.BasicLit{Kind: token.FLOAT, Value: "0.0"}
+			}
+		case *ast.UnaryExpr:
+			if e.Op == token.AND {
+				n.Results[i] = ast.NewIdent("nil")
+			}
+		case *ast.CallExpr:
+			if ident, ok := e.Fun.(*ast.Ident); ok && ident.Name == "new" {
+				n.Results[i] = ast.NewIdent("nil")
+			}
+		}
+	}
+	return "return ...", "return nil/0"
+}
+
+func (m *ReturnMutator) Unmutate(node ast.Node) {
+	n := node.(*ast.ReturnStmt)
+	copy(n.Results, m.orig)
+}
+
+func (*ReturnMutator) Clone() Mutator { return &ReturnMutator{} }
+
+// StatementMutator deletes expression statements and increment/decrement
+// statements by splicing an empty statement into their place.
+type StatementMutator struct {
+	orig ast.Stmt
+}
+
+func (*StatementMutator) Name() string { return "statement" }
+
+func (*StatementMutator) Description() string {
+	return "deletes expression and inc/dec statements"
+}
+
+func (*StatementMutator) CanMutate(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.ExprStmt, *ast.IncDecStmt:
+		_, ok := stmtLocs.Load(node.(ast.Stmt))
+		return ok
+	}
+	return false
+}
+
+func (m *StatementMutator) Mutate(node ast.Node) (string, string) {
+	stmt := node.(ast.Stmt)
+	loc, _ := stmtLocs.Load(stmt)
+	l := loc.(stmtLoc)
+	m.orig = l.block.List[l.index]
+	l.block.List[l.index] = &ast.EmptyStmt{Semicolon: stmt.Pos(), Implicit: true}
+	return fmt.Sprintf("%T", stmt), "(deleted)"
+}
+
+func (m *StatementMutator) Unmutate(node ast.Node) {
+	stmt := node.(ast.Stmt)
+	loc, _ := stmtLocs.Load(stmt)
+	l := loc.(stmtLoc)
+	l.block.List[l.index] = m.orig
+}
+
+func (*StatementMutator) Clone() Mutator { return &StatementMutator{} }
@@ -0,0 +1,87 @@
+package mutest
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+const mutatorFixture = `package fixture
+
+func classify(n int) int {
+	if n > 0 {
+		n++
+		return n + 1
+	}
+	for n < 10 {
+		n = n + 1
+	}
+	if n == 3 {
+		return 5
+	}
+	s := ""
+	_ = s
+	return n
+}
+`
+
+func TestMutatorRoundTrips(t *testing.T) {
+	mutators, err := Lookup([]string{"cond", "branch", "arith", "literal", "return", "statement"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mutator := range mutators {
+		mutator := mutator
+		t.Run(mutator.Name(), func(t *testing.T) {
+			fset := token.NewFileSet()
+			astFile, err := parser.ParseFile(fset, "fixture.go", mutatorFixture, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file := &File{fset: fset, name: "fixture.go", astFile: astFile, mutators: []Mutator{mutator}}
+			ast.Walk(file, astFile)
+
+			if len(file.candidates) == 0 {
+				t.Fatalf("%s found no candidates in the fixture", mutator.Name())
+			}
+
+			var original bytes.Buffer
+			if err := format.Node(&original, fset, astFile); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, c := range file.candidates {
+				before, after := c.mutator.Mutate(c.node)
+				if before == after {
+					t.Errorf("%s: Mutate reported before == after == %q", mutator.Name(), before)
+				}
+
+				var mutated bytes.Buffer
+				if err := format.Node(&mutated, fset, astFile); err != nil {
+					t.Fatalf("%s: mutant does not format: %s", mutator.Name(), err)
+				}
+				if bytes.Equal(mutated.Bytes(), original.Bytes()) {
+					t.Errorf("%s: Mutate left the source unchanged", mutator.Name())
+				}
+				if _, err := parser.ParseFile(token.NewFileSet(), "fixture.go", mutated.Bytes(), parser.ParseComments); err != nil {
+					t.Errorf("%s: mutant does not parse: %s", mutator.Name(), err)
+				}
+
+				c.mutator.Unmutate(c.node)
+
+				var restored bytes.Buffer
+				if err := format.Node(&restored, fset, astFile); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(restored.Bytes(), original.Bytes()) {
+					t.Errorf("%s: Unmutate did not restore the original source\nwant:\n%s\ngot:\n%s", mutator.Name(), original.String(), restored.String())
+				}
+			}
+		})
+	}
+}
@@ -2,22 +2,27 @@ package mutest
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/parser"
-	"go/printer"
 	"go/token"
-	"io/ioutil"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
-var nodeArray = make([]ast.Node, 0)
-var successfulMutations = make([]ast.Node, 0)
-var fset = token.NewFileSet()
+// candidate pairs a mutation site with the Mutator that found it, so a
+// single run can mix operators (e.g. cond and arith) without losing
+// track of which one to invoke.
+type candidate struct {
+	node    ast.Node
+	mutator Mutator
+}
 
 func check(e error) {
 	if e != nil {
@@ -28,226 +33,223 @@ func check(e error) {
 // File is a wrapper for the state of a file used in the parser.
 // The basic parse tree walker is a method of this type.
 type File struct {
-	fset      *token.FileSet
-	name      string // Name of file.
-	astFile   *ast.File
-	atomicPkg string // Package name for "sync/atomic" in this file.
+	fset       *token.FileSet
+	name       string // Name of file.
+	astFile    *ast.File
+	atomicPkg  string      // Package name for "sync/atomic" in this file.
+	mutators   []Mutator   // Active catalog for this walk.
+	candidates []candidate // Mutation sites found by Visit, in walk order.
 }
 
-// Mutates the node, runs the test, then un-mutates the node
-// Saves successful mutations to
-func runTest(node ast.Node, fset *token.FileSet, file *ast.File, filename string, mutator Mutator) []byte {
-	// Mutate the AST
-	beforeOp, afterOp := mutator.Mutate(node)
+// stmtLoc is where a statement sits in its enclosing block, so the
+// statement mutator can splice it out and back in again without needing
+// a parent pointer on ast.Stmt.
+type stmtLoc struct {
+	block *ast.BlockStmt
+	index int
+}
 
-	// Create new file
-	genFile, err := os.Create(filename)
-	check(err)
-	defer genFile.Close()
+// stmtLocs maps ast.Stmt to its stmtLoc. It is a sync.Map rather than a
+// plain map because multiple Runner workers walk independent ASTs (and
+// therefore independent keys) concurrently; a plain map would race.
+var stmtLocs sync.Map
+
+// nonLiteralStrings marks *ast.BasicLit string nodes that LiteralMutator
+// shouldn't touch because they aren't runtime string values: import
+// paths and struct tags. Like stmtLocs, it's a sync.Map because multiple
+// Runner workers walk independent ASTs concurrently.
+var nonLiteralStrings sync.Map
+
+// Mutates the node, runs `go test` in dir, then un-mutates the node,
+// returning a MutantResult classifying what happened. filename is where
+// the mutated source is written before the test runs; dir is passed as
+// the test command's working directory rather than relying on the
+// caller having os.Chdir'd there, so concurrent callers testing
+// different sandboxes never race on the process's cwd. runFilter, if
+// non-empty, is passed as `go test -run` so only tests that can actually
+// exercise the mutated line run; callers that haven't narrowed the
+// candidate down to specific tests (or aren't coverage-guided) pass "".
+//
+// The mutated AST is formatted with go/format rather than go/printer, to
+// keep mutant output readable in reports, and the formatted bytes are
+// re-parsed before anything is run: a mutation that leaves the AST in a
+// state format/parser can't round-trip (e.g. a malformed node produced
+// by a buggy Mutator) is Errored with a clear message instead of costing
+// a full `go test` invocation on source that was never going to build.
+//
+// The mutant is written and then restored via fs rather than os
+// directly, so callers testing in an in-memory sandbox can exercise this
+// without touching disk.
+//
+// The file is restored to its pre-mutation source rather than removed:
+// RunPackage tests every candidate from every file in a package against
+// one shared sandbox, so deleting filename after testing it would leave
+// later candidates (from this file or any other in the same package)
+// building against a package missing a source file, misclassifying
+// their result instead of actually running their test.
+func runTest(ctx context.Context, node ast.Node, fset *token.FileSet, file *ast.File, dir, filename, runFilter string, mutator Mutator, fs FS) MutantResult {
+	result := MutantResult{
+		File:    filename,
+		Pos:     fset.Position(node.Pos()),
+		Mutator: mutator.Name(),
+	}
+
+	start := time.Now()
+	result.Before, result.After = mutator.Mutate(node)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		mutator.Unmutate(node)
+		result.Status = Errored
+		result.TestOutput = []byte(fmt.Sprintf("could not format mutant: %s", err))
+		result.Duration = time.Since(start)
+		return result
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), filename, buf.Bytes(), parser.ParseComments); err != nil {
+		mutator.Unmutate(node)
+		result.Status = Errored
+		result.TestOutput = []byte(fmt.Sprintf("mutant does not parse: %s", err))
+		result.Duration = time.Since(start)
+		return result
+	}
 
-	// Write AST to file
-	printer.Fprint(genFile, fset, file)
-	genFile.Sync()
+	// Write the verified source to file
+	check(fs.Create(filename, buf.Bytes()))
 
 	// Exec
 	args := []string{"test"}
-	cmd := exec.Command("go", args...)
+	if runFilter != "" {
+		args = append(args, "-run", runFilter)
+	}
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
 	output, err := cmd.CombinedOutput()
-	if err == nil {
-		fmt.Println("Mutation did not cause a failure! From: ", beforeOp, " to ", afterOp, " pos: ", node.Pos())
-	} else if _, ok := err.(*exec.ExitError); ok {
-		lines := bytes.Split(output, []byte("\n"))
-		lastLine := lines[len(lines) - 2]
-		if !bytes.HasPrefix(lastLine, []byte("FAIL")) {
-			fmt.Fprintf(os.Stderr, "mutation %s to %s tests resulted in an error: %s\n", beforeOp, afterOp, lastLine)
+	result.TestOutput = output
+	result.Duration = time.Since(start)
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.Status = Timeout
+	case err == nil:
+		result.Status = Survived
+	default:
+		// A mutant that doesn't compile also ends in a FAIL line, tagged
+		// "[build failed]" (or "[setup failed]"); that is Errored, not Killed.
+		last := lastOutputLine(output)
+		buildFailed := bytes.HasSuffix(last, []byte("[build failed]")) || bytes.HasSuffix(last, []byte("[setup failed]"))
+		if _, ok := err.(*exec.ExitError); ok && bytes.HasPrefix(last, []byte("FAIL")) && !buildFailed {
+			result.Status = Killed
 		} else {
-			fmt.Println("mutation tests failed as expected! From", beforeOp, " to ", afterOp)
+			result.Status = Errored
 		}
-	} else {
-		fmt.Errorf("mutation failed to run tests: %s\n", err)
 	}
 
-	// Un-mutate AST
+	// Un-mutate AST, then restore filename to its pre-mutation source so
+	// it's left in a buildable state for whatever candidate runs next.
 	mutator.Unmutate(node)
 
-	// Remove file so next run will be clean
-	err = os.Remove(filename)
-	check(err)
-	return output
-}
+	var restored bytes.Buffer
+	check(format.Node(&restored, fset, file))
+	check(fs.Create(filename, restored.Bytes()))
 
+	return result
+}
 
+// lastOutputLine returns the last non-empty line of `go test` output,
+// which is where it prints its final FAIL/ok summary.
+func lastOutputLine(output []byte) []byte {
+	lines := bytes.Split(bytes.TrimRight(output, "\n"), []byte("\n"))
+	if len(lines) == 0 {
+		return nil
+	}
+	return lines[len(lines)-1]
+}
 
+// Mutator is a single mutation operator. CanMutate reports whether it
+// applies to a given AST node so File.Visit can dispatch to it without
+// knowing anything about the operator itself; Mutate/Unmutate describe
+// the change made (for reporting) and apply/revert it in place. Some
+// mutators (e.g. numeric literal bumps) need to remember the original
+// value between Mutate and Unmutate; Clone returns a fresh instance so
+// concurrent Runner workers each get their own memory for that instead
+// of racing on a shared one.
 type Mutator interface {
 	Name() string
 	Description() string
-	Mutate(node ast.Node) (token.Token, token.Token)
+	CanMutate(node ast.Node) bool
+	Mutate(node ast.Node) (before, after string)
 	Unmutate(node ast.Node)
+	Clone() Mutator
 }
 
-type SimpleMutator struct {}
-
-func (*SimpleMutator) Name() string {
-	return "SimpleMutator"
-}
-
-func (*SimpleMutator) Description() string {
-	return "SimpleMutator mutates binary and negation statements"
-}
-
-// Mutates a given node (i.e. switches '==' to '!=')
-func (*SimpleMutator) Mutate(node ast.Node) (token.Token, token.Token) {
-	var beforeOp, afterOp token.Token
-	switch n := node.(type) {
-	case *ast.BinaryExpr:
-		beforeOp = n.Op
-		switch n.Op {
-		case token.LAND:
-			n.Op = token.LOR
-		case token.LOR:
-			n.Op = token.LAND
-		case token.EQL:
-			n.Op = token.NEQ
-		case token.NEQ:
-			n.Op = token.EQL
-		case token.GEQ:
-			n.Op = token.LSS
-		case token.LEQ:
-			n.Op = token.GTR
-		case token.GTR:
-			n.Op = token.LEQ
-		case token.LSS:
-			n.Op = token.GEQ
-		default:
-			panic(n.Op)
+// Visit implements the ast.Visitor interface.
+// Finds candidates for mutating and adds them to f.candidates by asking
+// every mutator in f.mutators whether it applies to this node.
+func (f *File) Visit(node ast.Node) ast.Visitor {
+	if block, ok := node.(*ast.BlockStmt); ok {
+		for i, stmt := range block.List {
+			switch stmt.(type) {
+			case *ast.ExprStmt, *ast.IncDecStmt:
+				stmtLocs.Store(stmt, stmtLoc{block: block, index: i})
+			}
 		}
-		afterOp = n.Op
-	case *ast.UnaryExpr:
-		beforeOp = n.Op
-		n.X = &ast.UnaryExpr{OpPos: n.OpPos, Op: token.NOT, X: n.X}
-		afterOp = n.Op
 	}
-	return beforeOp, afterOp
-}
-
-func (m *SimpleMutator) Unmutate(node ast.Node) {
-	m.Mutate(node)
-}
-
-func addSides(node ast.Expr) {
 	switch n := node.(type) {
-	case *ast.BinaryExpr:
-		if n.Op == token.LAND || n.Op == token.LOR {
-			addSides(n.X)
-			addSides(n.Y)
+	case *ast.ImportSpec:
+		nonLiteralStrings.Store(n.Path, true)
+	case *ast.StructType:
+		for _, field := range n.Fields.List {
+			if field.Tag != nil {
+				nonLiteralStrings.Store(field.Tag, true)
+			}
 		}
-		nodeArray = append(nodeArray, node)
-	case *ast.UnaryExpr:
-		nodeArray = append(nodeArray, node)
 	}
-}
 
-// Visit implements the ast.Visitor interface.
-// Finds candidates for mutating and adds them to nodeArray
-func (f *File) Visit(node ast.Node) ast.Visitor {
-	switch n := node.(type) {
-	case *ast.ForStmt:
-		switch n := n.Cond.(type) {
-		case *ast.BinaryExpr:
-			if n.Op == token.LAND || n.Op == token.LOR {
-				addSides(n)
-			} else {
-				nodeArray = append(nodeArray, n)
-			}
-		case *ast.UnaryExpr:
-			nodeArray = append(nodeArray, n)
-		}
-	case *ast.IfStmt:
-		switch n := n.Cond.(type) {
-		case *ast.BinaryExpr:
-			if n.Op == token.LAND || n.Op == token.LOR {
-				addSides(n)
-			}
-			nodeArray = append(nodeArray, n)
-		case *ast.UnaryExpr:
-			if n.Op == token.NOT {
-				nodeArray = append(nodeArray, n)
-			}
+	for _, m := range f.mutators {
+		if m.CanMutate(node) {
+			f.candidates = append(f.candidates, candidate{node: node, mutator: m})
 		}
-	/*	case *ast.AssignStmt:
-			fmt.Println("ASSIGN statement: lhs: ", n.Lhs, " Tok: ", n.Tok, " rhs: ", n.Rhs)
-		case *ast.ReturnStmt:
-			fmt.Println("Return statement: return: ", n.Results)*/
 	}
 	return f
 }
 
-func doWork(codeFilePath, testFilePath string, mutator Mutator) [][]byte {
-	codeFileParts := strings.Split(codeFilePath, "/")
-	codeFilename := codeFileParts[len(codeFileParts) - 1]
-	testFileParts := strings.Split(testFilePath, "/")
-	testFilename := testFileParts[len(testFileParts) - 1]
-
-	// Read in Test File
-	dat, err := ioutil.ReadFile(testFilePath)
-	check(err)
-
-	// Read in and parse code file
+// Main parses the CLI flags and runs a mutation pass, writing the report
+// to stdout. It's exported so cmd/mutest's package main can stay a thin
+// wrapper, rather than duplicating flag definitions there.
+func Main() {
+	codeFilePathPtr := flag.String("c", "", "The path to the code file to mutate")
+	testFilePathPtr := flag.String("t", "", "The path to the test file against which to test mutations")
+	pkgPtr := flag.String("pkg", "", "Import path of the package to mutate, e.g. ./... to recurse through a module")
+	mutatorsPtr := flag.String("mutators", "cond,branch", "comma-separated list of mutators to run: arith,cond,branch,return,literal,statement")
+	jobsPtr := flag.Int("jobs", 1, "number of mutants to test concurrently")
+	reportPtr := flag.String("report", "text", "report format: text, json, or html")
+	coverageGuidedPtr := flag.Bool("coverage-guided", false, "skip mutants outside test-covered code, and narrow `go test` to the tests that cover each one")
+	tagsPtr := flag.String("tags", "", "comma-separated build tags, passed to go/build when resolving -pkg (ignored for -c/-t)")
+	flag.Parse()
 
-	name := codeFilePath
-	content, err := ioutil.ReadFile(name)
-	check(err)
-	parsedFile, err := parser.ParseFile(fset, name, content, 0)
+	mutators, err := Lookup(strings.Split(*mutatorsPtr, ","))
 	check(err)
 
-	file := &File{
-		fset:    fset,
-		name:    name,
-		astFile: parsedFile,
+	var buildTags []string
+	if *tagsPtr != "" {
+		buildTags = strings.Split(*tagsPtr, ",")
 	}
 
-	ast.Walk(file, file.astFile)
-	//ast.Fprint(os.Stdout, fset, file.astFile, ast.NotNilFilter)
-	//printer.Fprint(os.Stdout, fset, file.astFile)
-
-	fmt.Println("*****************************************************")
-	dir, err := os.Getwd()
-	check(err)
-	// Create a directory to test from
-	genPath := filepath.Join(dir, "..", "generated_mutest")
-	os.Mkdir(genPath, os.ModeDir | os.ModePerm)
-	check(err)
-	filename := filepath.Join(genPath, codeFilename)
-
-	// Copy the test file into the new directory
-	genTestFile, err := os.Create(filepath.Join(genPath, testFilename))
-	check(err)
-	defer genTestFile.Close()
-	err = ioutil.WriteFile(filepath.Join(genPath, testFilename), dat, 0644)
-	check(err)
-
-	err = os.Chdir(genPath)
-	check(err)
-
-	output := make([][]byte, 0)
-
-	for i := range nodeArray {
-		output = append(output, runTest(nodeArray[i], fset, file.astFile, filename, mutator))
+	var results []MutantResult
+	if *pkgPtr != "" {
+		opts := Options{
+			Mutators:       mutators,
+			Recursive:      strings.HasSuffix(*pkgPtr, "..."),
+			CoverageGuided: *coverageGuidedPtr,
+			Jobs:           *jobsPtr,
+			BuildTags:      buildTags,
+		}
+		results = RunPackage(*pkgPtr, opts)
+	} else {
+		runner := NewRunner(mutators, *jobsPtr)
+		runner.CoverageGuided = *coverageGuidedPtr
+		results = runner.Run(context.Background(), *codeFilePathPtr, *testFilePathPtr)
 	}
 
-	err = os.Chdir("../mutest")
-	check(err)
-	// Remove the created directory
-	err = os.RemoveAll(genPath)
-	check(err)
-	nodeArray = make([]ast.Node, 0)
-	return output
-}
-
-func main() {
-	codeFilePathPtr := flag.String("c", "", "The path to the code file to mutate")
-	testFilePathPtr := flag.String("t", "", "The path to the test file against which to test mutations")
-	flag.Parse()
-	mutator := &SimpleMutator{}
-	doWork(*codeFilePathPtr, *testFilePathPtr, mutator)
+	check(WriteReport(os.Stdout, *reportPtr, results))
 }
@@ -0,0 +1,344 @@
+package mutest
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Options controls how RunPackage discovers and mutates a package or
+// module.
+type Options struct {
+	Mutators  []Mutator
+	Recursive bool     // true for "./..." style import paths
+	BuildTags []string // passed through to build.Context.BuildTags
+
+	// CoverageGuided, when true, runs each package's test suite once with
+	// -coverprofile before mutating: candidates outside covered code are
+	// reported NotCovered without spending a `go test` invocation on
+	// them. It also runs each Test function individually with its own
+	// -coverprofile, so covered candidates are tested with -run narrowed
+	// to just the tests that actually reach that line.
+	CoverageGuided bool
+
+	// FS is the filesystem RunPackage sandboxes candidates' packages
+	// through (see copyModuleTree). Defaults to osFS{} when nil, so
+	// callers outside this package never need to set it; tests can plug
+	// in an in-memory implementation instead.
+	FS FS
+
+	// Jobs is how many files' candidates RunPackage tests concurrently
+	// (at least 1, the default). Candidates from the same file share one
+	// *ast.File that Mutate/Unmutate rewrites in place, so they always
+	// run sequentially within that file; Jobs only parallelizes across
+	// the distinct files a package (or "./..." tree) contains.
+	Jobs int
+}
+
+// pkgNode pairs a mutation candidate with the File it came from, so a
+// mutant can be written back to the right source file instead of the
+// first one the walk happened to visit.
+type pkgNode struct {
+	candidate candidate
+	file      *File
+}
+
+// buildContext returns a go/build.Context configured with opts.BuildTags,
+// falling back to build.Default when none are set.
+func buildContext(opts Options) build.Context {
+	ctx := build.Default
+	if len(opts.BuildTags) > 0 {
+		ctx.BuildTags = opts.BuildTags
+	}
+	return ctx
+}
+
+// findPackageDirs resolves importPath to the directories that should be
+// mutated. A non-recursive path resolves to a single directory; "./..."
+// (or "some/dir/...") walks the tree rooted at the part of importPath
+// before the trailing "..." and returns every directory that contains
+// a buildable Go package.
+func findPackageDirs(importPath string, opts Options) ([]string, error) {
+	ctx := buildContext(opts)
+
+	if !opts.Recursive {
+		pkg, err := ctx.Import(importPath, ".", 0)
+		if err != nil {
+			return nil, err
+		}
+		return []string{pkg.Dir}, nil
+	}
+
+	root := strings.TrimSuffix(importPath, "./...")
+	root = strings.TrimSuffix(root, "...")
+	if root == "" {
+		root = "."
+	}
+
+	dirs := make([]string, 0)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != root {
+			return filepath.SkipDir
+		}
+		if _, err := ctx.ImportDir(path, 0); err != nil {
+			// No buildable Go package here (e.g. no Go files); keep walking.
+			return nil
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
+// parsePackageDir parses every Go and test Go file that build.Context
+// reports for dir. Test files are parsed too, but they're assertions
+// rather than code to mutate, so they're kept out of the returned []*File
+// and their paths are returned separately in testPaths instead. Each File
+// carries its own clone of the full catalog from opts so its Visit
+// dispatches to every active mutator, and files tested concurrently never
+// share a stateful Mutator instance.
+func parsePackageDir(fset *token.FileSet, dir string, opts Options) (files []*File, testPaths []string, err error) {
+	ctx := buildContext(opts)
+	pkg, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(pkg.GoFiles)+len(pkg.TestGoFiles))
+	names = append(names, pkg.GoFiles...)
+	names = append(names, pkg.TestGoFiles...)
+
+	filter := func(fi os.FileInfo) bool {
+		for _, name := range names {
+			if fi.Name() == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	pkgs, err := parser.ParseDir(fset, dir, filter, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	isTestFile := make(map[string]bool, len(pkg.TestGoFiles))
+	for _, name := range pkg.TestGoFiles {
+		isTestFile[name] = true
+	}
+
+	files = make([]*File, 0, len(pkg.GoFiles))
+	for _, astPkg := range pkgs {
+		// ast.MergePackageFiles would collapse the package into a single
+		// synthetic *ast.File; walk the originals instead so each File
+		// keeps its own name and node ownership.
+		for name, astFile := range astPkg.Files {
+			if isTestFile[filepath.Base(name)] {
+				testPaths = append(testPaths, name)
+				continue
+			}
+			mutators := make([]Mutator, len(opts.Mutators))
+			for i, m := range opts.Mutators {
+				mutators[i] = m.Clone()
+			}
+			files = append(files, &File{
+				fset:     fset,
+				name:     name,
+				astFile:  astFile,
+				mutators: mutators,
+			})
+		}
+	}
+	return files, testPaths, nil
+}
+
+// collectPackageCandidates walks every file in files and returns each
+// mutation candidate paired with the File it belongs to.
+func collectPackageCandidates(files []*File) []pkgNode {
+	candidates := make([]pkgNode, 0)
+	for _, f := range files {
+		ast.Walk(f, f.astFile)
+		for _, c := range f.candidates {
+			candidates = append(candidates, pkgNode{candidate: c, file: f})
+		}
+	}
+	return candidates
+}
+
+// moduleRoot walks upward from dir looking for a go.mod, returning the
+// directory that contains it. It returns "" if none is found before
+// reaching the filesystem root, e.g. for a GOPATH-style package that
+// isn't part of a module.
+func moduleRoot(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(abs, "go.mod")); err == nil {
+			return abs, nil
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", nil
+		}
+		abs = parent
+	}
+}
+
+// RunPackage mutates every candidate found in importPath, which may name
+// a single package or, when opts.Recursive is set, a "./..." pattern
+// rooted at the current directory. It resolves GoFiles and TestGoFiles
+// via go/build so it can be pointed at a real project instead of a
+// hand-picked -c/-t file pair. Mutants are written and tested against a
+// sandboxed copy of each package's module (see copyModuleTree), so the
+// user's real source tree is never written to or removed.
+func RunPackage(importPath string, opts Options) []MutantResult {
+	dirs, err := findPackageDirs(importPath, opts)
+	check(err)
+
+	fs := opts.FS
+	if fs == nil {
+		fs = osFS{}
+	}
+
+	ctx := context.Background()
+
+	results := make([]MutantResult, 0)
+	for _, dir := range dirs {
+		results = append(results, runPackageDir(ctx, dir, opts, fs)...)
+	}
+	return results
+}
+
+// runPackageDir mutates every candidate found in dir, isolating that
+// directory's own errors from the rest of a recursive "./..." run: a
+// directory that doesn't build, or whose own tests already fail before
+// any mutation happens, is reported as a single Errored result instead of
+// panicking RunPackage and discarding every result already collected
+// from directories visited earlier. The sandbox is removed via defer
+// right after it's created, rather than at the bottom of the function,
+// so that early-return path cleans it up too.
+func runPackageDir(ctx context.Context, dir string, opts Options, fs FS) (results []MutantResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			results = []MutantResult{{
+				File:       dir,
+				Status:     Errored,
+				TestOutput: []byte(fmt.Sprintf("%v", r)),
+			}}
+		}
+	}()
+
+	fset := token.NewFileSet()
+	files, testPaths, err := parsePackageDir(fset, dir, opts)
+	check(err)
+
+	candidates := collectPackageCandidates(files)
+
+	sandboxDir, sandboxRoot, err := copyModuleTree(fs, "mutest-pkg-", dir)
+	check(err)
+	defer fs.Remove(sandboxRoot)
+
+	var coverage *coverageProfile
+	var perTest *testCoverage
+	if opts.CoverageGuided {
+		coverage, err = runCoverage(ctx, sandboxDir, "")
+		check(err)
+
+		names, err := testNames(testPaths...)
+		check(err)
+		perTest, err = runPerTestCoverage(ctx, sandboxDir, names)
+		check(err)
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// Candidates from the same file share one *ast.File that Mutate and
+	// Unmutate rewrite in place, so they must be tested one at a time;
+	// candidates from different files are independent and can run on
+	// separate workers. Group by file here and hand whole files, not
+	// individual candidates, out to the worker pool below.
+	byFile := make(map[*File][]int)
+	var fileOrder []*File
+	for i, c := range candidates {
+		if _, ok := byFile[c.file]; !ok {
+			fileOrder = append(fileOrder, c.file)
+		}
+		byFile[c.file] = append(byFile[c.file], i)
+	}
+
+	// Each worker tests in a sandbox of its own: workers sharing one would
+	// write their mutants into the same package directory and build
+	// against each other's mutations.
+	workerDirs := []string{sandboxDir}
+	for w := 1; w < jobs; w++ {
+		workerDir, workerRoot, err := copyModuleTree(fs, "mutest-pkg-", dir)
+		check(err)
+		defer fs.Remove(workerRoot)
+		workerDirs = append(workerDirs, workerDir)
+	}
+
+	results = make([]MutantResult, len(candidates))
+	work := make(chan *File)
+	go func() {
+		defer close(work)
+		for _, f := range fileOrder {
+			work <- f
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func(workerDir string) {
+			defer wg.Done()
+			for f := range work {
+				for _, i := range byFile[f] {
+					c := candidates[i]
+					realFile := filepath.Join(dir, filepath.Base(c.file.name))
+					sandboxFile := filepath.Join(workerDir, filepath.Base(c.file.name))
+					base := filepath.Base(c.file.name)
+					pos := fset.Position(c.candidate.node.Pos())
+
+					if !coverage.covers(base, pos) {
+						results[i] = MutantResult{
+							File:    realFile,
+							Pos:     pos,
+							Mutator: c.candidate.mutator.Name(),
+							Status:  NotCovered,
+						}
+						continue
+					}
+
+					runFilter := testFilter(perTest.coveringTests(base, pos)...)
+					result := runTest(ctx, c.candidate.node, fset, c.file.astFile, workerDir, sandboxFile, runFilter, c.candidate.mutator, fs)
+					result.File = realFile
+					results[i] = result
+				}
+			}
+		}(workerDirs[w])
+	}
+	wg.Wait()
+
+	return results
+}
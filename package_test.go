@@ -0,0 +1,150 @@
+package mutest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// chdir switches the test process into dir for the duration of the test,
+// restoring the original working directory on cleanup. findPackageDirs'
+// recursive walk always starts from the process's cwd, so exercising it
+// needs a real chdir rather than just a fixture path.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	prev, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(prev); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func writeGoFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindPackageDirsRecursive(t *testing.T) {
+	root := t.TempDir()
+	writeGoFile(t, filepath.Join(root, "go.mod"), "module fixture\n\ngo 1.21\n")
+	writeGoFile(t, filepath.Join(root, "root.go"), "package fixture\n")
+	writeGoFile(t, filepath.Join(root, "pkga", "a.go"), "package pkga\n")
+	writeGoFile(t, filepath.Join(root, "pkgb", "b.go"), "package pkgb\n")
+	writeGoFile(t, filepath.Join(root, "empty", "README.md"), "no go files here\n")
+	writeGoFile(t, filepath.Join(root, ".git", "stray.go"), "package stray\n")
+
+	chdir(t, root)
+
+	dirs, err := findPackageDirs("./...", Options{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, len(dirs))
+	for i, d := range dirs {
+		got[i] = filepath.Clean(d)
+	}
+	sort.Strings(got)
+
+	want := []string{".", "pkga", "pkgb"}
+	if len(got) != len(want) {
+		t.Fatalf("findPackageDirs(\"./...\") = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("findPackageDirs(\"./...\")[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFindPackageDirsNonRecursive(t *testing.T) {
+	root := t.TempDir()
+	writeGoFile(t, filepath.Join(root, "go.mod"), "module fixture\n\ngo 1.21\n")
+	writeGoFile(t, filepath.Join(root, "root.go"), "package fixture\n")
+	writeGoFile(t, filepath.Join(root, "pkga", "a.go"), "package pkga\n")
+
+	chdir(t, root)
+
+	dirs, err := findPackageDirs(".", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 {
+		t.Fatalf("findPackageDirs(\".\") = %v, want exactly the root dir", dirs)
+	}
+}
+
+func TestRunPackageDirRecoversFromBrokenPackage(t *testing.T) {
+	dir := t.TempDir()
+	writeGoFile(t, filepath.Join(dir, "go.mod"), "module broken\n\ngo 1.21\n")
+	// Deliberately malformed: parser.ParseDir will fail on this file,
+	// which is the failure runPackageDir's recover is meant to contain.
+	writeGoFile(t, filepath.Join(dir, "broken.go"), "package broken\n\nfunc Add(\n")
+
+	results := runPackageDir(context.Background(), dir, Options{}, newMemFS())
+
+	if len(results) != 1 {
+		t.Fatalf("runPackageDir on a broken package = %d results, want exactly 1 Errored result", len(results))
+	}
+	if results[0].Status != Errored {
+		t.Errorf("runPackageDir on a broken package: Status = %q, want %q", results[0].Status, Errored)
+	}
+	if results[0].File != dir {
+		t.Errorf("runPackageDir on a broken package: File = %q, want %q", results[0].File, dir)
+	}
+	if len(results[0].TestOutput) == 0 {
+		t.Error("runPackageDir on a broken package: TestOutput is empty, want the recovered panic message")
+	}
+}
+
+func TestRunPackageRecursiveSkipsBrokenDirsButKeepsOthers(t *testing.T) {
+	root := t.TempDir()
+	writeGoFile(t, filepath.Join(root, "go.mod"), "module fixture\n\ngo 1.21\n")
+	// "good" has no arithmetic for ArithMutator to find, so it produces no
+	// candidates and therefore never shells out to `go test`; this keeps
+	// the test deterministic while still proving RunPackage visited it.
+	writeGoFile(t, filepath.Join(root, "good", "calc.go"), "package good\n")
+	writeGoFile(t, filepath.Join(root, "bad", "broken.go"), "package bad\n\nfunc Oops(\n")
+
+	chdir(t, root)
+
+	mutators, err := Lookup([]string{"arith"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var panicked bool
+	var results []MutantResult
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				panicked = true
+			}
+		}()
+		results = RunPackage("./...", Options{Mutators: mutators, Recursive: true, FS: newMemFS()})
+	}()
+	if panicked {
+		t.Fatal("RunPackage(\"./...\") panicked instead of isolating the broken directory")
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("RunPackage(\"./...\") = %v, want exactly the bad directory's Errored result", results)
+	}
+	if results[0].Status != Errored || filepath.Base(results[0].File) != "bad" {
+		t.Errorf("RunPackage(\"./...\") results[0] = %+v, want an Errored result from the bad directory", results[0])
+	}
+}
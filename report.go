@@ -0,0 +1,199 @@
+package mutest
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Status classifies the outcome of testing a single mutant.
+type Status string
+
+const (
+	// Killed means the test suite failed against the mutant, as desired.
+	Killed Status = "killed"
+	// Survived means the test suite passed despite the mutation, which
+	// usually means the mutated code isn't tested thoroughly enough.
+	Survived Status = "survived"
+	// Errored means the mutant couldn't be built or tested at all (e.g.
+	// a compile error, or an invalid mutant caught before testing).
+	Errored Status = "errored"
+	// Timeout means the test run was killed because the run's context
+	// deadline passed before `go test` finished. A run killed by
+	// cancelling the context is reported Errored instead.
+	Timeout Status = "timeout"
+	// NotCovered means no test exercises this line, so the mutant was
+	// never run. See Options.CoverageGuided.
+	NotCovered Status = "not_covered"
+)
+
+// MutantResult is the outcome of testing a single mutant.
+type MutantResult struct {
+	File       string
+	Pos        token.Position
+	Mutator    string
+	Before     string
+	After      string
+	Status     Status
+	TestOutput []byte
+	Duration   time.Duration
+}
+
+// Score returns the mutation score, killed / (killed + survived), over
+// results. Errored, Timeout, and NotCovered mutants don't count either
+// way since they say nothing about whether the tests would have caught
+// the mutation.
+func Score(results []MutantResult) float64 {
+	var killed, survived int
+	for _, r := range results {
+		switch r.Status {
+		case Killed:
+			killed++
+		case Survived:
+			survived++
+		}
+	}
+	if killed+survived == 0 {
+		return 0
+	}
+	return float64(killed) / float64(killed+survived)
+}
+
+// WriteReport writes results to w in the given format: "json", "html",
+// or "text" (the default when format is empty).
+func WriteReport(w io.Writer, format string, results []MutantResult) error {
+	switch format {
+	case "json":
+		return writeJSONReport(w, results)
+	case "html":
+		return writeHTMLReport(w, results)
+	case "", "text":
+		return writeTextReport(w, results)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+func writeJSONReport(w io.Writer, results []MutantResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func writeTextReport(w io.Writer, results []MutantResult) error {
+	for _, r := range results {
+		fmt.Fprintf(w, "%-10s %s: %s mutated %q to %q (%s)\n", r.Status, r.Pos, r.Mutator, r.Before, r.After, r.Duration)
+	}
+	fmt.Fprintf(w, "mutation score: %.1f%% (%d mutants)\n", Score(results)*100, len(results))
+	return nil
+}
+
+// fileGroup is one source file's worth of results, for the HTML report.
+type fileGroup struct {
+	File    string
+	Source  []string // source lines, 1-indexed by Line-1
+	Results []MutantResult
+}
+
+// lineResults returns the results in g anchored to line (1-indexed).
+func (g fileGroup) lineResults(line int) []MutantResult {
+	found := make([]MutantResult, 0)
+	for _, r := range g.Results {
+		if r.Pos.Line == line {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"lineResults": fileGroup.lineResults,
+	"add":         func(a, b int) int { return a + b },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>mutest report</title>
+<style>
+body { font-family: monospace; }
+.score { font-size: 1.2em; margin-bottom: 1em; }
+.line { white-space: pre; }
+.mutated { background: #fdd; cursor: pointer; }
+.killed { color: #070; }
+.survived { color: #a00; font-weight: bold; }
+.errored, .timeout, .not_covered { color: #777; }
+.mutations { display: none; }
+pre.output { background: #f6f6f6; padding: 0.5em; }
+</style>
+</head>
+<body>
+<div class="score">mutation score: {{printf "%.1f" .Score}}% ({{len .Results}} mutants)</div>
+{{range .Files}}
+<h2>{{.File}}</h2>
+{{$file := .}}
+{{range $i, $src := .Source}}
+{{$lineNum := add $i 1}}
+{{$hits := lineResults $file $lineNum}}
+{{if $hits}}<div class="line mutated" onclick="document.getElementById('out-{{$file.File}}-{{$lineNum}}').style.display = document.getElementById('out-{{$file.File}}-{{$lineNum}}').style.display === 'block' ? 'none' : 'block'">{{$lineNum}}: {{$src}}</div>
+<div id="out-{{$file.File}}-{{$lineNum}}" class="mutations">
+{{range $hits}}<div class="{{.Status}}">{{.Mutator}}: {{.Before}} -&gt; {{.After}} [{{.Status}}]</div>
+<pre class="output">{{printf "%s" .TestOutput}}</pre>
+{{end}}
+</div>
+{{else}}<div class="line">{{$lineNum}}: {{$src}}</div>
+{{end}}
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+func writeHTMLReport(w io.Writer, results []MutantResult) error {
+	groups := make(map[string]*fileGroup)
+	order := make([]string, 0)
+	for _, r := range results {
+		g, ok := groups[r.File]
+		if !ok {
+			source := make([]string, 0)
+			if content, err := ioutil.ReadFile(r.File); err == nil {
+				source = splitLines(content)
+			}
+			g = &fileGroup{File: r.File, Source: source}
+			groups[r.File] = g
+			order = append(order, r.File)
+		}
+		g.Results = append(g.Results, r)
+	}
+
+	files := make([]fileGroup, 0, len(order))
+	for _, name := range order {
+		files = append(files, *groups[name])
+	}
+
+	data := struct {
+		Files   []fileGroup
+		Results []MutantResult
+		Score   float64
+	}{files, results, Score(results) * 100}
+
+	return htmlReportTemplate.Execute(w, data)
+}
+
+func splitLines(content []byte) []string {
+	lines := make([]string, 0)
+	start := 0
+	for i, b := range content {
+		if b == '\n' {
+			lines = append(lines, string(content[start:i]))
+			start = i + 1
+		}
+	}
+	if start < len(content) {
+		lines = append(lines, string(content[start:]))
+	}
+	return lines
+}
@@ -0,0 +1,89 @@
+package mutest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []MutantResult
+		want    float64
+	}{
+		{"no results", nil, 0},
+		{"all not covered", []MutantResult{{Status: NotCovered}, {Status: NotCovered}}, 0},
+		{"all killed", []MutantResult{{Status: Killed}, {Status: Killed}}, 1},
+		{"all survived", []MutantResult{{Status: Survived}, {Status: Survived}}, 0},
+		{"mixed", []MutantResult{{Status: Killed}, {Status: Survived}, {Status: Errored}, {Status: Timeout}, {Status: NotCovered}}, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Score(tt.results); got != tt.want {
+				t.Errorf("Score(%v) = %v, want %v", tt.results, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"empty", "", nil},
+		{"no trailing newline", "a\nb", []string{"a", "b"}},
+		{"trailing newline", "a\nb\n", []string{"a", "b"}},
+		{"single line no newline", "a", []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitLines([]byte(tt.content))
+			if len(got) != len(tt.want) {
+				t.Fatalf("splitLines(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("splitLines(%q)[%d] = %q, want %q", tt.content, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteReportFormats(t *testing.T) {
+	results := []MutantResult{
+		{File: "calc.go", Mutator: "cond", Before: "==", After: "!=", Status: Killed},
+		{File: "calc.go", Mutator: "arith", Before: "+", After: "-", Status: Survived},
+	}
+
+	for _, format := range []string{"text", "", "json", "html"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteReport(&buf, format, results); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() == 0 {
+				t.Fatalf("WriteReport(%q) produced no output", format)
+			}
+		})
+	}
+
+	t.Run("killed and survived text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteReport(&buf, "text", results); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "mutation score: 50.0%") {
+			t.Errorf("text report = %q, want it to contain the 50%% mutation score", buf.String())
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		if err := WriteReport(&bytes.Buffer{}, "yaml", results); err == nil {
+			t.Fatal("WriteReport with an unknown format should return an error")
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package mutest
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FS abstracts the filesystem operations a Runner needs to set up and
+// tear down worker sandboxes, so tests can plug in an in-memory
+// implementation instead of touching disk.
+type FS interface {
+	MkdirAll(path string) error
+	Create(path string, content []byte) error
+	Remove(path string) error
+	Copy(src, dst string) error
+	TempDir(pattern string) (string, error)
+	Chdir(path string) error
+}
+
+// osFS is the FS backed by the real filesystem.
+type osFS struct{}
+
+func (osFS) MkdirAll(path string) error {
+	return os.MkdirAll(path, 0755)
+}
+
+func (osFS) Create(path string, content []byte) error {
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+func (osFS) Remove(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (osFS) Copy(src, dst string) error {
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, content, 0644)
+}
+
+func (osFS) TempDir(pattern string) (string, error) {
+	return ioutil.TempDir("", pattern)
+}
+
+func (osFS) Chdir(path string) error {
+	return os.Chdir(path)
+}
+
+// Runner drives a mutation run. Unlike doWork's former reliance on the
+// package-level nodeArray/fset globals, a Runner keeps all per-run state
+// to itself, so multiple Runners (or multiple workers within one Runner)
+// never interfere with each other.
+type Runner struct {
+	Mutators []Mutator
+	Jobs     int
+	FS       FS
+
+	// CoverageGuided, when true, runs the test suite once with
+	// -coverprofile before mutating: candidates outside covered code are
+	// reported NotCovered without spending a `go test` invocation on
+	// them. It also runs each Test function in testFilePath individually
+	// with its own -coverprofile, so covered candidates are tested with
+	// -run narrowed to just the tests that actually reach that line.
+	CoverageGuided bool
+}
+
+// NewRunner builds a Runner with jobs workers (at least 1) testing
+// mutants produced by mutators.
+func NewRunner(mutators []Mutator, jobs int) *Runner {
+	if jobs < 1 {
+		jobs = 1
+	}
+	return &Runner{Mutators: mutators, Jobs: jobs, FS: osFS{}}
+}
+
+// workerSandbox is the scratch directory a single worker mutates and
+// tests in. It is created once and reused for every mutant the worker is
+// assigned, rather than per mutant, so workers don't pay setup cost on
+// every test run.
+type workerSandbox struct {
+	dir          string // where `go test` runs; a sandboxed copy of codeFilePath's package
+	root         string // sandbox root to remove once the worker is done
+	codeFilename string
+}
+
+// copyModuleTree copies the module containing dir (or, lacking a
+// go.mod, just dir itself) into a fresh sandbox directory via fs, so
+// `go test` run there resolves go.mod and sibling package files instead
+// of failing with "go.mod file not found". It returns the sandbox path
+// equivalent to dir, and the sandbox root fs.Remove should clean up.
+func copyModuleTree(fs FS, pattern, dir string) (sandboxDir, root string, err error) {
+	// moduleRoot always returns an absolute path, so dir must be made
+	// absolute too before the Rel call below: Rel refuses to relate an
+	// absolute base to a relative target (e.g. the filepath.Dir(codeFilePath)
+	// Runner.Run passes for a bare "-c calc.go" invocation), and panics
+	// check(err) into the caller.
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	base, err := moduleRoot(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if base == "" {
+		base = dir
+	}
+
+	root, err = fs.TempDir(pattern)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(root, rel)
+		if info.IsDir() {
+			return fs.MkdirAll(target)
+		}
+		return fs.Copy(path, target)
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	rel, err := filepath.Rel(base, dir)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(root, rel), root, nil
+}
+
+// newWorkerSandbox copies the module containing codeFilePath into a
+// fresh sandbox via r.FS (see copyModuleTree), so the worker can `go
+// test` there with go.mod and sibling package files present, without
+// touching the original module. testFilePath is copied in separately in
+// case it doesn't live alongside codeFilePath.
+func (r *Runner) newWorkerSandbox(id int, codeFilePath, testFilePath string) (*workerSandbox, error) {
+	sandboxDir, root, err := copyModuleTree(r.FS, fmt.Sprintf("mutest-worker-%d-", id), filepath.Dir(codeFilePath))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.FS.Copy(testFilePath, filepath.Join(sandboxDir, filepath.Base(testFilePath))); err != nil {
+		return nil, err
+	}
+	return &workerSandbox{dir: sandboxDir, root: root, codeFilename: filepath.Base(codeFilePath)}, nil
+}
+
+// deriveCandidates parses codeFilePath with its own FileSet and walks it
+// with mutators, returning the parsed file alongside the ordered
+// candidates found. Re-running it on the same input reproduces the same
+// order, which is what lets a bare candidate index identify the same
+// site across independent parses done by different workers.
+//
+// mutators are cloned before the walk so the candidates this call
+// produces own private Mutator instances: a couple of operators (e.g.
+// literal, return) remember the pre-mutation value on themselves between
+// Mutate and Unmutate, and sharing one instance across concurrent
+// workers would race on that state.
+func deriveCandidates(codeFilePath string, mutators []Mutator) (*token.FileSet, *ast.File, []candidate, error) {
+	localFset := token.NewFileSet()
+	content, err := ioutil.ReadFile(codeFilePath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	astFile, err := parser.ParseFile(localFset, codeFilePath, content, parser.ParseComments)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cloned := make([]Mutator, len(mutators))
+	for i, m := range mutators {
+		cloned[i] = m.Clone()
+	}
+
+	file := &File{fset: localFset, name: codeFilePath, astFile: astFile, mutators: cloned}
+	ast.Walk(file, astFile)
+
+	return localFset, astFile, file.candidates, nil
+}
+
+// Run mutates codeFilePath once per candidate the Runner's mutators
+// find, testing each mutant in its own worker sandbox so mutants run
+// concurrently without sharing AST or filesystem state: each worker
+// parses its own copy of codeFilePath once, rather than mutating a tree
+// shared with other workers. It stops handing out new mutants once ctx
+// is done; in-flight `go test` invocations are killed via
+// exec.CommandContext.
+func (r *Runner) Run(ctx context.Context, codeFilePath, testFilePath string) []MutantResult {
+	_, _, candidates, err := deriveCandidates(codeFilePath, r.Mutators)
+	check(err)
+
+	var coverage *coverageProfile
+	var perTest *testCoverage
+	if r.CoverageGuided {
+		probe, err := r.newWorkerSandbox(-1, codeFilePath, testFilePath)
+		check(err)
+		coverage, err = runCoverage(ctx, probe.dir, "")
+		check(err)
+
+		names, err := testNames(testFilePath)
+		check(err)
+		perTest, err = runPerTestCoverage(ctx, probe.dir, names)
+		check(err)
+
+		r.FS.Remove(probe.root)
+	}
+
+	sites := make(chan int)
+	go func() {
+		defer close(sites)
+		for i := range candidates {
+			select {
+			case sites <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make([]MutantResult, len(candidates))
+	var wg sync.WaitGroup
+	for w := 0; w < r.Jobs; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+
+			sandbox, err := r.newWorkerSandbox(id, codeFilePath, testFilePath)
+			check(err)
+			defer r.FS.Remove(sandbox.root)
+
+			// Parsed once per worker, not once per mutant: Unmutate and
+			// runTest's post-test restore leave this AST (and the sandbox
+			// file it's serialized into) exactly as they started, so every
+			// candidate this worker is assigned can reuse the same parse
+			// instead of re-reading and re-walking codeFilePath from
+			// scratch for each one.
+			localFset, astFile, localCandidates, err := deriveCandidates(codeFilePath, r.Mutators)
+			check(err)
+
+			for i := range sites {
+				results[i] = r.runMutant(ctx, codeFilePath, i, localFset, astFile, localCandidates, sandbox, coverage, perTest)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runMutant applies the candidate at index from an AST already parsed
+// for the calling worker by Run, and tests it in sandbox. The result's
+// File is reported as codeFilePath rather than the sandbox copy runTest
+// actually wrote to, since the sandbox path is worker-private and
+// meaningless to anyone reading the report. If coverage is non-nil and
+// the candidate's position isn't covered, the mutant is reported
+// NotCovered without running `go test` at all; otherwise the run is
+// narrowed to whichever tests perTest says actually reach that position.
+func (r *Runner) runMutant(ctx context.Context, codeFilePath string, index int, localFset *token.FileSet, astFile *ast.File, candidates []candidate, sandbox *workerSandbox, coverage *coverageProfile, perTest *testCoverage) MutantResult {
+	if index >= len(candidates) {
+		return MutantResult{}
+	}
+	c := candidates[index]
+	pos := localFset.Position(c.node.Pos())
+
+	if !coverage.covers(sandbox.codeFilename, pos) {
+		return MutantResult{
+			File:    codeFilePath,
+			Pos:     pos,
+			Mutator: c.mutator.Name(),
+			Status:  NotCovered,
+		}
+	}
+
+	runFilter := testFilter(perTest.coveringTests(sandbox.codeFilename, pos)...)
+	target := filepath.Join(sandbox.dir, sandbox.codeFilename)
+	result := runTest(ctx, c.node, localFset, astFile, sandbox.dir, target, runFilter, c.mutator, r.FS)
+	result.File = codeFilePath
+	return result
+}
@@ -0,0 +1,149 @@
+package mutest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memFS is an in-memory FS used by tests so sandbox setup/teardown can be
+// exercised without touching the real filesystem.
+type memFS struct {
+	mu      sync.Mutex
+	counter int
+	files   map[string][]byte
+	dirs    map[string]bool
+}
+
+func newMemFS() *memFS {
+	return &memFS{files: make(map[string][]byte), dirs: make(map[string]bool)}
+}
+
+func (fs *memFS) MkdirAll(path string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.dirs[path] = true
+	return nil
+}
+
+func (fs *memFS) Create(path string, content []byte) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.files[path] = append([]byte(nil), content...)
+	return nil
+}
+
+func (fs *memFS) Remove(path string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for p := range fs.files {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			delete(fs.files, p)
+		}
+	}
+	for d := range fs.dirs {
+		if d == path || strings.HasPrefix(d, path+"/") {
+			delete(fs.dirs, d)
+		}
+	}
+	return nil
+}
+
+func (fs *memFS) Copy(src, dst string) error {
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return fs.Create(dst, content)
+}
+
+func (fs *memFS) TempDir(pattern string) (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.counter++
+	dir := fmt.Sprintf("/mem/%s%d", pattern, fs.counter)
+	fs.dirs[dir] = true
+	return dir, nil
+}
+
+func (fs *memFS) Chdir(path string) error { return nil }
+
+// writeFixtureModule lays out a minimal module (go.mod plus one package
+// file) under a fresh t.TempDir, returning its root.
+func writeFixtureModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module fixture\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "calc.go"), []byte("package fixture\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCopyModuleTree(t *testing.T) {
+	dir := writeFixtureModule(t)
+	fs := newMemFS()
+
+	sandboxDir, root, err := copyModuleTree(fs, "mutest-test-", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFile := filepath.Join(sandboxDir, "calc.go")
+	got, ok := fs.files[wantFile]
+	if !ok {
+		t.Fatalf("copyModuleTree did not copy %s into the sandbox; have %v", wantFile, fs.files)
+	}
+	if !strings.Contains(string(got), "func Add") {
+		t.Fatalf("copied file content = %q, want it to contain func Add", got)
+	}
+
+	if !fs.dirs[root] {
+		t.Fatalf("sandbox root %s was never recorded as a directory", root)
+	}
+
+	if err := fs.Remove(root); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.files) != 0 {
+		t.Fatalf("files remain after Remove(root): %v", fs.files)
+	}
+	if len(fs.dirs) != 0 {
+		t.Fatalf("dirs remain after Remove(root): %v", fs.dirs)
+	}
+}
+
+func TestNewWorkerSandbox(t *testing.T) {
+	dir := writeFixtureModule(t)
+	testPath := filepath.Join(dir, "calc_test.go")
+	if err := os.WriteFile(testPath, []byte("package fixture\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Runner{FS: newMemFS()}
+	sandbox, err := r.newWorkerSandbox(0, filepath.Join(dir, "calc.go"), testPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := r.FS.(*memFS)
+	if _, ok := fs.files[filepath.Join(sandbox.dir, "calc.go")]; !ok {
+		t.Fatalf("worker sandbox is missing calc.go: %v", fs.files)
+	}
+	if _, ok := fs.files[filepath.Join(sandbox.dir, "calc_test.go")]; !ok {
+		t.Fatalf("worker sandbox is missing calc_test.go: %v", fs.files)
+	}
+
+	if err := r.FS.Remove(sandbox.root); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.files) != 0 {
+		t.Fatalf("files remain after tearing down the sandbox: %v", fs.files)
+	}
+}